@@ -0,0 +1,206 @@
+package bot
+
+import (
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/innogames/slack-bot/client"
+	"github.com/innogames/slack-bot/config"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Authorizer decides whether a user may run a given command text. It is
+// consulted both for the coarse "is this user whitelisted at all" check in
+// HandleMessage and by commands.Run before dispatching to a specific
+// command, so per-command ACLs (e.g. only #ops may run "deploy") are
+// enforced in one place.
+type Authorizer interface {
+	IsAllowed(userID string, commandText string) bool
+
+	// StartSync periodically re-syncs usergroup membership in the
+	// background so a user added to e.g. #ops shows up without a restart.
+	StartSync(interval time.Duration)
+}
+
+// commandACL restricts a command (matched by a regexp against the command
+// text) to members of a set of usergroups, configured as e.g.
+//
+//	commands:
+//	  - match: "deploy .*"
+//	    allowed_groups: [ops]
+type commandACL struct {
+	pattern       *regexp.Regexp
+	allowedGroups []string
+}
+
+// groupAuthorizer is the default Authorizer: a global whitelist plus
+// optional per-command usergroup ACLs, both backed by lazily- and
+// periodically-refreshed usergroup membership.
+type groupAuthorizer struct {
+	mu sync.RWMutex
+
+	slackClient *client.Slack
+	logger      *log.Logger
+
+	allowedUsers    map[string]bool
+	whitelistGroups []string // only these count towards the global whitelist
+	syncGroups      []string // whitelistGroups plus every group referenced by an ACL, kept in sync together
+	groupMembers    map[string]map[string]bool
+	acls            []commandACL
+}
+
+// newGroupAuthorizer builds an Authorizer from config: the flat whitelist
+// (already resolved to user IDs by Bot.loadSlackData) plus the per-command
+// ACL rules from the `commands` config section.
+func newGroupAuthorizer(cfg config.Config, allowedUsers map[string]string, slackClient *client.Slack, logger *log.Logger) (*groupAuthorizer, error) {
+	a := &groupAuthorizer{
+		slackClient:     slackClient,
+		logger:          logger,
+		allowedUsers:    map[string]bool{},
+		whitelistGroups: cfg.Slack.AllowedGroups,
+		syncGroups:      append([]string{}, cfg.Slack.AllowedGroups...),
+		groupMembers:    map[string]map[string]bool{},
+	}
+
+	for userID := range allowedUsers {
+		a.allowedUsers[userID] = true
+	}
+
+	for _, rule := range cfg.Commands {
+		pattern, err := regexp.Compile(rule.Match)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid command ACL pattern: %s", rule.Match)
+		}
+		a.acls = append(a.acls, commandACL{pattern: pattern, allowedGroups: rule.AllowedGroups})
+
+		// ACL groups must stay out of whitelistGroups (they only grant
+		// access to the matching command, not every command) but they
+		// still need to be kept warm by the periodic sync.
+		a.syncGroups = append(a.syncGroups, rule.AllowedGroups...)
+	}
+
+	if err := a.sync(); err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// IsAllowed reports whether userID may run commandText: if an ACL rule
+// matches commandText, the user must be a member of one of that rule's
+// allowed usergroups (which grants access to the matching command only);
+// otherwise the user must be in the global whitelist (by ID or by name, same
+// as the old flat list).
+func (a *groupAuthorizer) IsAllowed(userID string, commandText string) bool {
+	for _, acl := range a.acls {
+		if !acl.pattern.MatchString(commandText) {
+			continue
+		}
+
+		return a.isInAnyGroup(userID, acl.allowedGroups)
+	}
+
+	return a.isWhitelisted(userID)
+}
+
+func (a *groupAuthorizer) isWhitelisted(userID string) bool {
+	a.mu.RLock()
+	allowed := a.allowedUsers[userID]
+	a.mu.RUnlock()
+	if allowed {
+		return true
+	}
+
+	// the user isn't in the static whitelist: check whether they've since
+	// joined one of the globally allowed groups instead of locking them out
+	// until the next restart. Groups that only appear in a per-command ACL
+	// must NOT grant global access, or a user added to e.g. #ops to run
+	// "deploy" would be able to run every other command too.
+	for _, group := range a.whitelistGroups {
+		if a.isInGroup(userID, group) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (a *groupAuthorizer) isInAnyGroup(userID string, groups []string) bool {
+	for _, group := range groups {
+		if a.isInGroup(userID, group) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isInGroup checks the cached membership of group, lazily fetching it from
+// the Slack API only on a true cache miss. A cache hit is trusted even when
+// it says the user isn't a member, otherwise every message from a
+// non-member would re-hit usergroups.users.list live instead of waiting for
+// the next periodic sync.
+func (a *groupAuthorizer) isInGroup(userID string, group string) bool {
+	a.mu.RLock()
+	members, ok := a.groupMembers[group]
+	a.mu.RUnlock()
+	if ok {
+		return members[userID]
+	}
+
+	members, err := a.fetchGroupMembers(group)
+	if err != nil {
+		a.logger.Warnf("error fetching usergroup %s: %s", group, err)
+		return false
+	}
+
+	return members[userID]
+}
+
+func (a *groupAuthorizer) fetchGroupMembers(group string) (map[string]bool, error) {
+	userIDs, err := a.slackClient.GetUserGroupMembers(group)
+	if err != nil {
+		return nil, err
+	}
+
+	members := make(map[string]bool, len(userIDs))
+	for _, userID := range userIDs {
+		members[userID] = true
+	}
+
+	a.mu.Lock()
+	a.groupMembers[group] = members
+	a.mu.Unlock()
+
+	return members, nil
+}
+
+// sync refreshes the membership of every configured usergroup (both the
+// global whitelist groups and every group referenced by a per-command ACL).
+func (a *groupAuthorizer) sync() error {
+	for _, group := range a.syncGroups {
+		if _, err := a.fetchGroupMembers(group); err != nil {
+			return errors.Wrapf(err, "error syncing usergroup %s", group)
+		}
+	}
+
+	return nil
+}
+
+// StartSync periodically re-syncs usergroup membership in the background.
+func (a *groupAuthorizer) StartSync(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	go func() {
+		for range ticker.C {
+			if err := a.sync(); err != nil {
+				a.logger.Warnf("error syncing usergroups: %s", err)
+			}
+		}
+	}()
+}
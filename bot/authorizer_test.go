@@ -0,0 +1,74 @@
+package bot
+
+import (
+	"regexp"
+	"testing"
+)
+
+func newTestAuthorizer() *groupAuthorizer {
+	return &groupAuthorizer{
+		allowedUsers:    map[string]bool{"U_WHITELISTED": true},
+		whitelistGroups: []string{"everyone"},
+		groupMembers: map[string]map[string]bool{
+			"everyone": {"U_WHITELISTED": true},
+			"ops":      {"U_OPS": true},
+		},
+		acls: []commandACL{
+			{pattern: regexp.MustCompile("^deploy .*"), allowedGroups: []string{"ops"}},
+		},
+	}
+}
+
+func TestGroupAuthorizer_IsAllowed(t *testing.T) {
+	tests := []struct {
+		name    string
+		userID  string
+		command string
+		want    bool
+	}{
+		{"whitelisted user, unrestricted command", "U_WHITELISTED", "help", true},
+		{"non-whitelisted user, unrestricted command", "U_STRANGER", "help", false},
+		{"ops member, ACL-matched command", "U_OPS", "deploy staging", true},
+		{"whitelisted non-ops user, ACL-matched command", "U_WHITELISTED", "deploy staging", false},
+		{"non-whitelisted user, ACL-matched command", "U_STRANGER", "deploy staging", false},
+		{"ops member, ACL-unmatched command", "U_OPS", "help", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestAuthorizer()
+			if got := a.IsAllowed(tt.userID, tt.command); got != tt.want {
+				t.Errorf("IsAllowed(%q, %q) = %v, want %v", tt.userID, tt.command, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestGroupAuthorizer_IsInGroup_TrustsNegativeCache ensures a cached "not a
+// member" result is trusted instead of refetching on every call.
+func TestGroupAuthorizer_IsInGroup_TrustsNegativeCache(t *testing.T) {
+	a := newTestAuthorizer()
+	a.groupMembers["ops"] = map[string]bool{"U_OPS": true}
+
+	if a.isInGroup("U_STRANGER", "ops") {
+		t.Fatal("expected U_STRANGER not to be in ops")
+	}
+
+	// the cache entry was set directly above: if isInGroup were still
+	// refetching on a negative hit, it would go through fetchGroupMembers (and
+	// panic on the nil slackClient) instead of returning this cached false.
+	if a.isInGroup("U_STRANGER", "ops") {
+		t.Fatal("expected cached negative membership to stick")
+	}
+}
+
+// TestGroupAuthorizer_ACLGroupDoesNotGrantGlobalWhitelist is a regression
+// test for the privilege-escalation bug where a group only referenced by a
+// per-command ACL used to also satisfy the global whitelist check.
+func TestGroupAuthorizer_ACLGroupDoesNotGrantGlobalWhitelist(t *testing.T) {
+	a := newTestAuthorizer()
+
+	if a.isWhitelisted("U_OPS") {
+		t.Fatal("membership in an ACL-only group must not satisfy the global whitelist")
+	}
+}
@@ -4,9 +4,9 @@ import (
 	"github.com/innogames/slack-bot/bot/util"
 	"github.com/innogames/slack-bot/client"
 	"github.com/innogames/slack-bot/config"
-	"github.com/nlopes/slack"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
+	"github.com/slack-go/slack"
 	"os"
 	"strings"
 	"time"
@@ -24,6 +24,8 @@ func NewBot(cfg config.Config, slackClient *client.Slack, logger *log.Logger, co
 		logger:       logger,
 		commands:     commands,
 		allowedUsers: map[string]string{},
+		userCache:    newUserCache(slackClient),
+		channelCache: newChannelCache(slackClient),
 	}
 }
 
@@ -34,6 +36,10 @@ type Bot struct {
 	auth         *slack.AuthTestResponse
 	commands     *Commands
 	allowedUsers map[string]string
+	userCache    *UserCache
+	channelCache *ChannelCache
+	transport    EventSource
+	authorizer   Authorizer
 }
 
 // Init establish slack connection and load allowed users
@@ -48,15 +54,21 @@ func (b *Bot) Init() (err error) {
 		return errors.Wrap(err, "auth error")
 	}
 
-	go b.slackClient.ManageConnection()
+	b.transport = newTransport(b.config.Slack, b.slackClient, b.logger)
+	if err = b.transport.Start(); err != nil {
+		return errors.Wrap(err, "error starting slack transport")
+	}
+
+	if b.config.Slack.ErrorChannel != "" {
+		b.logger.AddHook(NewSlackHook(b.config.Slack.Token, b.config.Slack.ErrorChannel))
+	}
 
 	channels, err := b.slackClient.GetChannels(true)
 	if err != nil {
 		return errors.Wrap(err, "error while fetching public channels")
 	}
-	client.Channels = make(map[string]string, len(channels))
-	for _, channel := range channels {
-		client.Channels[channel.ID] = channel.Name
+	for i := range channels {
+		b.channelCache.set(&channels[i])
 	}
 
 	err = b.loadSlackData()
@@ -64,6 +76,16 @@ func (b *Bot) Init() (err error) {
 		return err
 	}
 
+	authorizer, err := newGroupAuthorizer(b.config, b.allowedUsers, b.slackClient, b.logger)
+	if err != nil {
+		return errors.Wrap(err, "error setting up authorizer")
+	}
+	b.authorizer = authorizer
+	b.authorizer.StartSync(b.config.Slack.GroupSyncInterval)
+	b.commands.SetAuthorizer(b.authorizer)
+
+	go b.handleAdminEvents()
+
 	if len(b.config.Slack.AutoJoinChannels) > 0 {
 		for _, channel := range b.config.Slack.AutoJoinChannels {
 			_, err := b.slackClient.JoinChannel(channel)
@@ -75,7 +97,7 @@ func (b *Bot) Init() (err error) {
 		b.logger.Infof("Auto joined channels: %s", strings.Join(b.config.Slack.AutoJoinChannels, ", "))
 	}
 
-	b.logger.Infof("Loaded %d allowed users and %d channels", len(b.allowedUsers), len(client.Channels))
+	b.logger.Infof("Loaded %d allowed users and %d channels", len(b.allowedUsers), b.channelCache.len())
 	b.logger.Infof("Bot user: %s with ID: %s", b.auth.User, b.auth.UserID)
 	b.logger.Infof("Initialized %d commands", b.commands.Count())
 
@@ -113,34 +135,39 @@ func (b *Bot) loadSlackData() error {
 		}
 	}
 
-	client.Users = b.allowedUsers
+	for userID, userName := range b.allowedUsers {
+		b.userCache.set(&slack.User{ID: userID, Name: userName})
+	}
 
 	return nil
 }
 
 func (b *Bot) Disconnect() error {
+	if b.transport != nil {
+		if err := b.transport.Close(); err != nil {
+			return err
+		}
+	}
+
 	return b.slackClient.Disconnect()
 }
 
 // HandleMessages is blocking method to handle new incoming events
 func (b *Bot) HandleMessages(kill chan os.Signal) {
+	go b.handleInteractions()
+
 	for {
 		select {
-		case msg := <-b.slackClient.IncomingEvents:
-			// message received from user
-			switch message := msg.Data.(type) {
-			case *slack.MessageEvent:
-				if b.shouldHandleMessage(message) {
-					go b.HandleMessage(*message)
-				}
-			case *slack.LatencyReport:
-				b.logger.Debugf("Current latency: %v\n", message.Value)
+		case event := <-b.transport.Events():
+			// message received from user, regardless of the transport it came in on
+			if b.shouldHandleMessage(event) {
+				go b.HandleMessage(event)
 			}
 		case msg := <-client.InternalMessages:
 			// e.g. triggered by "delay" or "macro" command. They are still executed in original event context
 			// -> will post in same channel as the user posted the original command
 			msg.SubType = TypeInternal
-			b.HandleMessage(msg)
+			b.HandleMessage(MessageEvent{Msg: msg})
 		case <-kill:
 			b.Disconnect()
 			b.logger.Warnf("Shutdown!")
@@ -149,7 +176,13 @@ func (b *Bot) HandleMessages(kill chan os.Signal) {
 	}
 }
 
-func (b Bot) shouldHandleMessage(event *slack.MessageEvent) bool {
+func (b Bot) shouldHandleMessage(event MessageEvent) bool {
+	// edited messages are only re-run when explicitly enabled, since
+	// re-executing a command on every edit can surprise users
+	if event.Edited && !b.config.Slack.HandleEdits {
+		return false
+	}
+
 	// exclude all bot traffic
 	if event.BotID != "" || event.User == "" || event.User == b.auth.UserID || event.SubType == "bot_message" {
 		return false
@@ -178,7 +211,7 @@ func (b Bot) trimMessage(msg string) string {
 }
 
 // HandleMessage process the incoming message and respond appropriately
-func (b Bot) HandleMessage(event slack.MessageEvent) {
+func (b Bot) HandleMessage(event MessageEvent) {
 	event.Text = b.trimMessage(event.Text)
 	if event.Text == "" {
 		return
@@ -187,20 +220,34 @@ func (b Bot) HandleMessage(event slack.MessageEvent) {
 	start := time.Now()
 	logger := b.getLogger(event)
 
-	// send "bot is typing" command
-	b.slackClient.RTM.SendMessage(b.slackClient.NewTypingMessage(event.Channel))
+	// send "bot is typing" indicator, if the active transport supports it
+	if notifier, ok := b.transport.(typingNotifier); ok {
+		notifier.SendTyping(event.Channel)
+	}
 
-	_, existing := b.allowedUsers[event.User]
-	if !existing && event.SubType != TypeInternal && b.config.Slack.TestEndpointUrl == "" {
+	if event.SubType != TypeInternal && b.config.Slack.TestEndpointUrl == "" && !b.authorizer.IsAllowed(event.User, event.Text) {
 		logger.Errorf("user %s is not allowed to execute message: %s", event.User, event.Text)
 		b.slackClient.Reply(event, "Sorry, you are not whitelisted yet. Please ask the slack-bot admin to get access.")
 		return
 	}
 
-	if !b.commands.Run(event) {
+	if event.Edited {
+		logger.Infof("re-running edited message: %s", event.Text)
+	}
+
+	handled, reply := b.commands.Run(event)
+	if !handled {
 		logger.Infof("Unknown command: %s", event.Text)
 		b.sendFallbackMessage(event)
+	} else if !reply.IsEmpty() {
+		if err := b.slackClient.SendReply(event, reply.Text, reply.Attachments, reply.Blocks); err != nil {
+			logger.Errorf("error sending reply: %s", err)
+		}
+	}
+
+	if event.Edited && b.config.Slack.EditSuffix != "" {
+		b.slackClient.Reply(event, b.config.Slack.EditSuffix)
 	}
 
 	logger.Infof("handled message: %s in %s", event.Text, util.FormatDuration(time.Now().Sub(start)))
-}
\ No newline at end of file
+}
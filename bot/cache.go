@@ -0,0 +1,218 @@
+package bot
+
+import (
+	"sync"
+
+	"github.com/innogames/slack-bot/client"
+	"github.com/pkg/errors"
+	"github.com/slack-go/slack"
+)
+
+func errChannelNotFound(name string) error {
+	return errors.Errorf("channel not found: %s", name)
+}
+
+// AdminEvent is the subset of non-message RTM events the caches care about:
+// team_join, user_change, channel_created, channel_rename and
+// member_joined_channel. Transports which can deliver these implement
+// adminEventSource.
+type AdminEvent struct {
+	Type      string
+	UserID    string
+	ChannelID string
+}
+
+// adminEventSource is implemented by transports that can also deliver the
+// housekeeping events the caches subscribe to for invalidation.
+type adminEventSource interface {
+	AdminEvents() <-chan AdminEvent
+}
+
+// UserCache lazily resolves and caches Slack users by ID, refreshing entries
+// as team_join/user_change events come in instead of requiring a restart.
+type UserCache struct {
+	mu          sync.RWMutex
+	users       map[string]*slack.User
+	slackClient *client.Slack
+}
+
+func newUserCache(slackClient *client.Slack) *UserCache {
+	return &UserCache{
+		users:       map[string]*slack.User{},
+		slackClient: slackClient,
+	}
+}
+
+// getUser returns the cached user, lazily fetching it from the Slack API on
+// a cache miss.
+func (c *UserCache) getUser(userID string) (*slack.User, error) {
+	c.mu.RLock()
+	user, ok := c.users[userID]
+	c.mu.RUnlock()
+	if ok {
+		return user, nil
+	}
+
+	return c.populateUser(userID)
+}
+
+// populateUser fetches a single user from the Slack API and stores it,
+// overwriting any existing entry.
+func (c *UserCache) populateUser(userID string) (*slack.User, error) {
+	user, err := c.slackClient.GetUserInfo(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.users[userID] = user
+	c.mu.Unlock()
+
+	return user, nil
+}
+
+// set stores/overwrites a user in the cache, used to warm it with data we
+// already fetched for another purpose (e.g. the allowed-users list).
+func (c *UserCache) set(user *slack.User) {
+	c.mu.Lock()
+	c.users[user.ID] = user
+	c.mu.Unlock()
+}
+
+// invalidateUser drops a cached entry so the next getUser call refetches it.
+func (c *UserCache) invalidateUser(userID string) {
+	c.mu.Lock()
+	delete(c.users, userID)
+	c.mu.Unlock()
+}
+
+func (c *UserCache) len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return len(c.users)
+}
+
+// ChannelCache lazily resolves and caches Slack channels both by ID and by
+// name, refreshing entries as channel_created/channel_rename events come in.
+type ChannelCache struct {
+	mu          sync.RWMutex
+	byID        map[string]*slack.Channel
+	byName      map[string]string
+	slackClient *client.Slack
+}
+
+func newChannelCache(slackClient *client.Slack) *ChannelCache {
+	return &ChannelCache{
+		byID:        map[string]*slack.Channel{},
+		byName:      map[string]string{},
+		slackClient: slackClient,
+	}
+}
+
+func (c *ChannelCache) getChannelByID(channelID string) (*slack.Channel, error) {
+	c.mu.RLock()
+	channel, ok := c.byID[channelID]
+	c.mu.RUnlock()
+	if ok {
+		return channel, nil
+	}
+
+	return c.populateChannel(channelID)
+}
+
+func (c *ChannelCache) getChannelByName(name string) (*slack.Channel, error) {
+	c.mu.RLock()
+	channelID, ok := c.byName[name]
+	c.mu.RUnlock()
+	if ok {
+		return c.getChannelByID(channelID)
+	}
+
+	// not cached yet: refresh the whole channel list once and retry
+	channels, err := c.slackClient.GetChannels(true)
+	if err != nil {
+		return nil, err
+	}
+	for i := range channels {
+		c.set(&channels[i])
+	}
+
+	c.mu.RLock()
+	channelID, ok = c.byName[name]
+	c.mu.RUnlock()
+	if !ok {
+		return nil, errChannelNotFound(name)
+	}
+
+	return c.getChannelByID(channelID)
+}
+
+func (c *ChannelCache) populateChannel(channelID string) (*slack.Channel, error) {
+	channel, err := c.slackClient.GetChannelInfo(channelID)
+	if err != nil {
+		return nil, err
+	}
+
+	c.set(channel)
+
+	return channel, nil
+}
+
+// set stores/overwrites a channel in both lookup maps, used both for lazy
+// fetches and for applying channel_created/channel_rename updates.
+func (c *ChannelCache) set(channel *slack.Channel) {
+	c.mu.Lock()
+	c.byID[channel.ID] = channel
+	c.byName[channel.Name] = channel.ID
+	c.mu.Unlock()
+}
+
+// invalidateChannel drops a cached entry, including its stale byName
+// mapping, so the next lookup refetches current data (needed for renames,
+// where the old name would otherwise keep pointing at the channel).
+func (c *ChannelCache) invalidateChannel(channelID string) {
+	c.mu.Lock()
+	if channel, ok := c.byID[channelID]; ok {
+		delete(c.byName, channel.Name)
+	}
+	delete(c.byID, channelID)
+	c.mu.Unlock()
+}
+
+func (c *ChannelCache) len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return len(c.byID)
+}
+
+// handleAdminEvent applies a single team_join/user_change/channel_created/
+// channel_rename/member_joined_channel event to the caches. It only
+// invalidates, the next getUser/getChannelByID/getChannelByName call lazily
+// refetches the fresh data.
+func (b *Bot) handleAdminEvent(event AdminEvent) {
+	switch event.Type {
+	case "team_join", "user_change", "member_joined_channel":
+		if event.UserID != "" {
+			b.userCache.invalidateUser(event.UserID)
+		}
+	case "channel_created", "channel_rename":
+		if event.ChannelID != "" {
+			b.channelCache.invalidateChannel(event.ChannelID)
+		}
+	}
+}
+
+// handleAdminEvents is run alongside HandleMessages and keeps the caches
+// fresh for as long as the active transport can deliver housekeeping events.
+func (b *Bot) handleAdminEvents() {
+	source, ok := b.transport.(adminEventSource)
+	if !ok {
+		return
+	}
+
+	for event := range source.AdminEvents() {
+		b.handleAdminEvent(event)
+	}
+}
@@ -0,0 +1,82 @@
+package bot
+
+import (
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/slack-go/slack"
+)
+
+// color used for the Slack attachment per log level: both levels the hook
+// fires on use Slack's own "danger" color.
+var errorHookColors = map[log.Level]string{
+	log.FatalLevel: "danger",
+	log.ErrorLevel: "danger",
+}
+
+// SlackHook is a logrus.Hook which forwards Error/Fatal log entries to a
+// dedicated Slack channel, so operators can see bot failures without tailing
+// logs. It posts with its own *slack.Client, separate from the bot's main
+// client, so a failure while posting never triggers another log entry that
+// would recurse back into itself.
+type SlackHook struct {
+	client  *slack.Client
+	channel string
+}
+
+// NewSlackHook creates a hook posting to channel using token. The hook builds
+// its own fresh client from token rather than reusing the bot's, see SlackHook.
+func NewSlackHook(token string, channel string) *SlackHook {
+	return &SlackHook{
+		client:  slack.New(token),
+		channel: channel,
+	}
+}
+
+// Levels returns the log levels this hook fires on, as required by
+// logrus.Hook. Warnings are deliberately excluded: things like a rejected
+// signature or an unknown interaction payload log at Warn on every probe or
+// retry, and would otherwise spam the error channel.
+func (h *SlackHook) Levels() []log.Level {
+	return []log.Level{log.FatalLevel, log.ErrorLevel}
+}
+
+// Fire posts the given entry to the configured Slack channel.
+func (h *SlackHook) Fire(entry *log.Entry) error {
+	attachment := slack.Attachment{
+		Color: errorHookColors[entry.Level],
+		Text:  entry.Message,
+	}
+
+	for _, field := range []string{"user", "channel", "command"} {
+		if value, ok := entry.Data[field]; ok {
+			attachment.Fields = append(attachment.Fields, slack.AttachmentField{
+				Title: field,
+				Value: valueToString(value),
+				Short: true,
+			})
+		}
+	}
+
+	if stack, ok := entry.Data["stack"]; ok {
+		attachment.Fields = append(attachment.Fields, slack.AttachmentField{
+			Title: "stack",
+			Value: valueToString(stack),
+		})
+	}
+
+	_, _, err := h.client.PostMessage(
+		h.channel,
+		slack.MsgOptionAttachments(attachment),
+	)
+
+	return err
+}
+
+func valueToString(value interface{}) string {
+	if str, ok := value.(string); ok {
+		return str
+	}
+
+	return fmt.Sprintf("%v", value)
+}
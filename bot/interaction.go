@@ -0,0 +1,114 @@
+package bot
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/socketmode"
+)
+
+// InteractionHandler is implemented by commands which want to receive
+// interactive component callbacks (button clicks, select menu choices,
+// modal submissions) that were triggered by a message they previously sent.
+// CanHandleInteraction decides whether this command owns the given
+// callback, based on the CallbackID/ActionID it chose when building the
+// original reply.
+type InteractionHandler interface {
+	CanHandleInteraction(callback slack.InteractionCallback) bool
+	HandleInteraction(callback slack.InteractionCallback) bool
+}
+
+// interactionSource is implemented by transports which can receive
+// interactive component payloads in addition to plain messages (currently
+// Socket Mode and the Events API webhook; classic RTM has no equivalent).
+type interactionSource interface {
+	Interactions() <-chan slack.InteractionCallback
+}
+
+// handleInteractions is run alongside HandleMessages and routes incoming
+// interactive callbacks to whichever registered command recognizes them.
+func (b *Bot) handleInteractions() {
+	source, ok := b.transport.(interactionSource)
+	if !ok {
+		return
+	}
+
+	for callback := range source.Interactions() {
+		b.dispatchInteraction(callback)
+	}
+}
+
+func (b Bot) dispatchInteraction(callback slack.InteractionCallback) {
+	logger := b.logger.WithField("user", callback.User.ID)
+
+	if !b.commands.HandleInteraction(callback) {
+		logger.Warnf("no command handled interaction callback: %s", callback.CallbackID)
+	}
+}
+
+// socket mode / events api plumbing to surface interaction callbacks
+
+func parseInteractionPayload(rawPayload string) (slack.InteractionCallback, error) {
+	var callback slack.InteractionCallback
+	err := json.Unmarshal([]byte(rawPayload), &callback)
+
+	return callback, err
+}
+
+func (t *socketModeTransport) Interactions() <-chan slack.InteractionCallback {
+	return t.interactionEvents
+}
+
+func (t *socketModeTransport) dispatchInteractive(evt socketmode.Event) {
+	callback, ok := evt.Data.(slack.InteractionCallback)
+	if !ok {
+		t.logger.Warnf("unexpected interactive payload: %v", evt.Data)
+		return
+	}
+	t.client.Ack(*evt.Request)
+
+	t.interactionEvents <- callback
+}
+
+func (t *eventsAPITransport) Interactions() <-chan slack.InteractionCallback {
+	return t.interactionEvents
+}
+
+// handleInteraction is the HTTP handler for Slack's "Interactivity &
+// Shortcuts" request URL, which POSTs a url-encoded "payload" field rather
+// than a JSON body.
+func (t *eventsAPITransport) handleInteraction(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	if err := t.verifySignature(r.Header, body); err != nil {
+		t.logger.Warnf("rejected interaction request: %s", err)
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	callback, err := parseInteractionPayload(values.Get("payload"))
+	if err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	// ack before handing off to t.interactionEvents, same reasoning as
+	// eventsAPITransport.handle: the command triggered by this callback can
+	// easily outlive Slack's 3s retry window.
+	w.WriteHeader(http.StatusOK)
+
+	go func() { t.interactionEvents <- callback }()
+}
@@ -0,0 +1,21 @@
+package bot
+
+import "github.com/slack-go/slack"
+
+// Reply is a rich response a command can hand back instead of a plain
+// string. Attachments/Blocks may carry buttons or select menus whose
+// CallbackID/ActionID the originating command recognizes in
+// InteractionHandler.CanHandleInteraction, so pressing them re-enters the
+// same command instead of requiring the user to retype it.
+type Reply struct {
+	Text        string
+	Attachments []slack.Attachment
+	Blocks      []slack.Block
+}
+
+// IsEmpty reports whether the command produced nothing to send back, as
+// opposed to e.g. a command which already replied itself and only needs
+// Commands.Run to report it as handled.
+func (r Reply) IsEmpty() bool {
+	return r.Text == "" && len(r.Attachments) == 0 && len(r.Blocks) == 0
+}
@@ -0,0 +1,383 @@
+package bot
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/innogames/slack-bot/client"
+	"github.com/innogames/slack-bot/config"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+	"github.com/slack-go/slack/socketmode"
+)
+
+// maxRequestAge is how far a request's X-Slack-Request-Timestamp may drift
+// from now before it's rejected as a replay, per Slack's recommendation.
+const maxRequestAge = 5 * time.Minute
+
+// EventSource is implemented by every transport the bot can receive messages
+// from (classic RTM, Socket Mode, the Events API webhook). HandleMessages
+// only depends on this interface, so it doesn't need to care which transport
+// is actually in use.
+type EventSource interface {
+	// Start connects/starts listening for events. It must not block.
+	Start() error
+
+	// Events returns the channel new messages are delivered on.
+	Events() <-chan MessageEvent
+
+	// Close shuts down the underlying connection.
+	Close() error
+}
+
+// MessageEvent is the transport-agnostic representation of an incoming
+// message, regardless of whether it was received via RTM, Socket Mode or the
+// Events API webhook.
+type MessageEvent struct {
+	slack.Msg
+
+	// Edited is set when this event was generated from a "message_changed"
+	// subtype, as opposed to a freshly posted message.
+	Edited bool
+}
+
+// newTransport picks the transport to use based on the given config,
+// preferring Socket Mode, then the Events API webhook, falling back to the
+// classic RTM connection used by older setups.
+func newTransport(cfg config.SlackConfig, slackClient *client.Slack, logger *log.Logger) EventSource {
+	switch {
+	case cfg.SocketToken != "":
+		return newSocketModeTransport(slackClient, cfg.SocketToken, logger)
+	case cfg.ListenAddr != "":
+		return newEventsAPITransport(slackClient, cfg.SigningSecret, cfg.ListenAddr, logger)
+	default:
+		return newRtmTransport(slackClient)
+	}
+}
+
+// rtmTransport is the legacy transport based on the realtime-messaging
+// connection. It is kept around for workspaces/setups which don't configure
+// a socket_token or listen_addr yet.
+type rtmTransport struct {
+	slackClient *client.Slack
+	events      chan MessageEvent
+	adminEvents chan AdminEvent
+	done        chan struct{}
+}
+
+func newRtmTransport(slackClient *client.Slack) *rtmTransport {
+	return &rtmTransport{
+		slackClient: slackClient,
+		events:      make(chan MessageEvent),
+		adminEvents: make(chan AdminEvent),
+		done:        make(chan struct{}),
+	}
+}
+
+func (t *rtmTransport) Start() error {
+	go t.slackClient.ManageConnection()
+	go t.run()
+
+	return nil
+}
+
+func (t *rtmTransport) run() {
+	for {
+		select {
+		case msg := <-t.slackClient.IncomingEvents:
+			switch event := msg.Data.(type) {
+			case *slack.MessageEvent:
+				t.events <- messageEventFromRTM(event)
+			case *slack.TeamJoinEvent:
+				t.adminEvents <- AdminEvent{Type: "team_join", UserID: event.User.ID}
+			case *slack.UserChangeEvent:
+				t.adminEvents <- AdminEvent{Type: "user_change", UserID: event.User.ID}
+			case *slack.ChannelCreatedEvent:
+				t.adminEvents <- AdminEvent{Type: "channel_created", ChannelID: event.Channel.ID}
+			case *slack.ChannelRenameEvent:
+				t.adminEvents <- AdminEvent{Type: "channel_rename", ChannelID: event.Channel.ID}
+			case *slack.MemberJoinedChannelEvent:
+				t.adminEvents <- AdminEvent{Type: "member_joined_channel", UserID: event.User}
+			}
+		case <-t.done:
+			return
+		}
+	}
+}
+
+func (t *rtmTransport) Events() <-chan MessageEvent {
+	return t.events
+}
+
+// AdminEvents surfaces the team_join/user_change/channel_created/
+// channel_rename/member_joined_channel events the caches invalidate on.
+// Socket Mode and the Events API webhook don't subscribe to these, so only
+// the RTM transport implements adminEventSource.
+func (t *rtmTransport) AdminEvents() <-chan AdminEvent {
+	return t.adminEvents
+}
+
+func (t *rtmTransport) Close() error {
+	close(t.done)
+
+	return t.slackClient.Disconnect()
+}
+
+// SendTyping sends the "user is typing" indicator. Only the RTM transport
+// supports this, Socket Mode and the Events API have no equivalent.
+func (t *rtmTransport) SendTyping(channel string) {
+	t.slackClient.RTM.SendMessage(t.slackClient.NewTypingMessage(channel))
+}
+
+// typingNotifier is implemented by transports which can signal the "user is
+// typing" indicator back to Slack.
+type typingNotifier interface {
+	SendTyping(channel string)
+}
+
+// socketModeTransport receives events over a Socket Mode websocket
+// connection, authenticated with an app-level token. It doesn't require any
+// inbound connectivity, so it works for bots running behind a firewall.
+type socketModeTransport struct {
+	client            *socketmode.Client
+	events            chan MessageEvent
+	interactionEvents chan slack.InteractionCallback
+	logger            *log.Logger
+}
+
+func newSocketModeTransport(slackClient *client.Slack, socketToken string, logger *log.Logger) *socketModeTransport {
+	api := slack.New(
+		slackClient.GetToken(),
+		slack.OptionAppLevelToken(socketToken),
+	)
+
+	return &socketModeTransport{
+		client:            socketmode.New(api),
+		events:            make(chan MessageEvent),
+		interactionEvents: make(chan slack.InteractionCallback),
+		logger:            logger,
+	}
+}
+
+func (t *socketModeTransport) Start() error {
+	go t.client.Run()
+	go t.run()
+
+	return nil
+}
+
+func (t *socketModeTransport) run() {
+	for evt := range t.client.Events {
+		switch evt.Type {
+		case socketmode.EventTypeEventsAPI:
+			eventsAPIEvent, ok := evt.Data.(slackevents.EventsAPIEvent)
+			if !ok {
+				t.logger.Warnf("unexpected socket mode payload: %v", evt.Data)
+				continue
+			}
+			t.client.Ack(*evt.Request)
+
+			t.dispatch(eventsAPIEvent)
+		case socketmode.EventTypeInteractive:
+			t.dispatchInteractive(evt)
+		}
+	}
+}
+
+func (t *socketModeTransport) dispatch(eventsAPIEvent slackevents.EventsAPIEvent) {
+	if eventsAPIEvent.Type != slackevents.CallbackEvent {
+		return
+	}
+
+	switch ev := eventsAPIEvent.InnerEvent.Data.(type) {
+	case *slackevents.MessageEvent:
+		t.events <- messageEventFromSlackevents(ev)
+	}
+}
+
+func (t *socketModeTransport) Events() <-chan MessageEvent {
+	return t.events
+}
+
+func (t *socketModeTransport) Close() error {
+	return nil
+}
+
+// eventsAPITransport exposes an HTTP handler which Slack can push Events API
+// callbacks to. Every request is verified against slack.signing_secret
+// before being processed.
+type eventsAPITransport struct {
+	signingSecret     string
+	listenAddr        string
+	events            chan MessageEvent
+	interactionEvents chan slack.InteractionCallback
+	logger            *log.Logger
+	server            *http.Server
+}
+
+func newEventsAPITransport(slackClient *client.Slack, signingSecret string, listenAddr string, logger *log.Logger) *eventsAPITransport {
+	return &eventsAPITransport{
+		signingSecret:     signingSecret,
+		listenAddr:        listenAddr,
+		events:            make(chan MessageEvent),
+		interactionEvents: make(chan slack.InteractionCallback),
+		logger:            logger,
+	}
+}
+
+func (t *eventsAPITransport) Start() error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", t.handle)
+	mux.HandleFunc("/interactions", t.handleInteraction)
+
+	t.server = &http.Server{Addr: t.listenAddr, Handler: mux}
+
+	go func() {
+		if err := t.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			t.logger.Errorf("events api listener stopped: %s", err)
+		}
+	}()
+
+	return nil
+}
+
+func (t *eventsAPITransport) handle(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	if err := t.verifySignature(r.Header, body); err != nil {
+		t.logger.Warnf("rejected events api request: %s", err)
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	eventsAPIEvent, err := slackevents.ParseEvent(body, slackevents.OptionNoVerifyToken())
+	if err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	if eventsAPIEvent.Type == slackevents.URLVerification {
+		var challenge slackevents.ChallengeResponse
+		if err := json.Unmarshal(body, &challenge); err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "text")
+		_, _ = w.Write([]byte(challenge.Challenge))
+		return
+	}
+
+	// ack before handing off to t.events: Slack retries the whole request if
+	// it doesn't see a 2xx within 3s, and commands can easily run longer than
+	// that, so the response must not wait on the (unbuffered) events channel.
+	w.WriteHeader(http.StatusOK)
+
+	if eventsAPIEvent.Type == slackevents.CallbackEvent {
+		if ev, ok := eventsAPIEvent.InnerEvent.Data.(*slackevents.MessageEvent); ok {
+			go func() { t.events <- messageEventFromSlackevents(ev) }()
+		}
+	}
+}
+
+// verifySignature enforces Slack's signed-request verification scheme,
+// see https://api.slack.com/authentication/verifying-requests-from-slack
+func (t *eventsAPITransport) verifySignature(header http.Header, body []byte) error {
+	timestamp := header.Get("X-Slack-Request-Timestamp")
+	signature := header.Get("X-Slack-Signature")
+	if timestamp == "" || signature == "" {
+		return errors.New("missing signature headers")
+	}
+
+	requestTime, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errors.New("invalid request timestamp")
+	}
+	if age := time.Since(time.Unix(requestTime, 0)); age < -maxRequestAge || age > maxRequestAge {
+		return errors.New("request timestamp too far from now, possible replay")
+	}
+
+	mac := hmac.New(sha256.New, []byte(t.signingSecret))
+	mac.Write([]byte(fmt.Sprintf("v0:%s:", timestamp)))
+	mac.Write(body)
+	expected := "v0=" + hex.EncodeToString(mac.Sum(nil))
+
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return errors.New("signature mismatch")
+	}
+
+	return nil
+}
+
+func (t *eventsAPITransport) Events() <-chan MessageEvent {
+	return t.events
+}
+
+func (t *eventsAPITransport) Close() error {
+	if t.server == nil {
+		return nil
+	}
+
+	return t.server.Close()
+}
+
+// messageEventFromRTM builds a MessageEvent from a classic RTM message. For
+// a "message_changed" subtype, the edited text/user and its timestamps live in
+// SubMessage, while the top-level event carries the channel to reply into.
+func messageEventFromRTM(event *slack.MessageEvent) MessageEvent {
+	if event.SubType != "message_changed" || event.SubMessage == nil {
+		return MessageEvent{Msg: event.Msg}
+	}
+
+	// thread_ts lives on the nested sub-message, not the message_changed
+	// wrapper itself, so keep edited.ThreadTimestamp as copied from it
+	edited := *event.SubMessage
+	edited.Channel = event.Channel
+
+	return MessageEvent{Msg: edited, Edited: true}
+}
+
+func messageEventFromSlackevents(ev *slackevents.MessageEvent) MessageEvent {
+	// for a "message_changed" subtype, the edited text/user lives in the
+	// nested Message together with the thread to reply into, while ev itself
+	// keeps the channel
+	if strings.EqualFold(ev.SubType, "message_changed") && ev.Message != nil {
+		return MessageEvent{
+			Msg: slack.Msg{
+				Type:            ev.Message.Type,
+				User:            ev.Message.User,
+				Text:            ev.Message.Text,
+				Timestamp:       ev.Message.TimeStamp,
+				Channel:         ev.Channel,
+				ThreadTimestamp: ev.Message.ThreadTimeStamp,
+			},
+			Edited: true,
+		}
+	}
+
+	return MessageEvent{
+		Msg: slack.Msg{
+			Type:            ev.Type,
+			User:            ev.User,
+			Text:            ev.Text,
+			Timestamp:       ev.TimeStamp,
+			Channel:         ev.Channel,
+			SubType:         ev.SubType,
+			ThreadTimestamp: ev.ThreadTimeStamp,
+			BotID:           ev.BotID,
+		},
+	}
+}
@@ -0,0 +1,167 @@
+package bot
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+)
+
+func signRequest(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(fmt.Sprintf("v0:%s:", timestamp)))
+	mac.Write(body)
+
+	return "v0=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestEventsAPITransport_verifySignature(t *testing.T) {
+	transport := &eventsAPITransport{signingSecret: "shhh"}
+	body := []byte(`{"type":"event_callback"}`)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	t.Run("valid signature", func(t *testing.T) {
+		header := http.Header{}
+		header.Set("X-Slack-Request-Timestamp", timestamp)
+		header.Set("X-Slack-Signature", signRequest("shhh", timestamp, body))
+
+		if err := transport.verifySignature(header, body); err != nil {
+			t.Errorf("expected valid signature to be accepted, got: %s", err)
+		}
+	})
+
+	t.Run("invalid signature", func(t *testing.T) {
+		header := http.Header{}
+		header.Set("X-Slack-Request-Timestamp", timestamp)
+		header.Set("X-Slack-Signature", signRequest("wrong-secret", timestamp, body))
+
+		if err := transport.verifySignature(header, body); err == nil {
+			t.Error("expected signature mismatch to be rejected")
+		}
+	})
+
+	t.Run("missing headers", func(t *testing.T) {
+		if err := transport.verifySignature(http.Header{}, body); err == nil {
+			t.Error("expected missing signature headers to be rejected")
+		}
+	})
+
+	t.Run("stale timestamp", func(t *testing.T) {
+		old := strconv.FormatInt(time.Now().Add(-10*time.Minute).Unix(), 10)
+		header := http.Header{}
+		header.Set("X-Slack-Request-Timestamp", old)
+		header.Set("X-Slack-Signature", signRequest("shhh", old, body))
+
+		if err := transport.verifySignature(header, body); err == nil {
+			t.Error("expected a stale request timestamp to be rejected as a possible replay")
+		}
+	})
+}
+
+func TestMessageEventFromRTM(t *testing.T) {
+	t.Run("plain message", func(t *testing.T) {
+		event := &slack.MessageEvent{
+			Msg: slack.Msg{Channel: "C1", User: "U1", Text: "hi", Timestamp: "100.001", ThreadTimestamp: "100.000"},
+		}
+
+		got := messageEventFromRTM(event)
+
+		if got.Edited {
+			t.Error("expected a plain message not to be marked as edited")
+		}
+		if got.Channel != "C1" || got.User != "U1" || got.Text != "hi" {
+			t.Errorf("got %+v, want channel/user/text from the top-level message", got)
+		}
+		if got.ThreadTimestamp != "100.000" {
+			t.Errorf("ThreadTimestamp = %q, want %q", got.ThreadTimestamp, "100.000")
+		}
+	})
+
+	t.Run("message_changed", func(t *testing.T) {
+		event := &slack.MessageEvent{
+			Msg: slack.Msg{Channel: "C1", SubType: "message_changed"},
+			SubMessage: &slack.Msg{
+				User:            "U2",
+				Text:            "edited text",
+				Timestamp:       "200.001",
+				ThreadTimestamp: "199.000",
+			},
+		}
+
+		got := messageEventFromRTM(event)
+
+		if !got.Edited {
+			t.Error("expected a message_changed event to be marked as edited")
+		}
+		if got.Channel != "C1" {
+			t.Errorf("Channel = %q, want %q from the wrapper event", got.Channel, "C1")
+		}
+		if got.User != "U2" || got.Text != "edited text" {
+			t.Errorf("got %+v, want user/text from the nested sub-message", got)
+		}
+		if got.ThreadTimestamp != "199.000" {
+			t.Errorf("ThreadTimestamp = %q, want %q from the nested sub-message", got.ThreadTimestamp, "199.000")
+		}
+	})
+}
+
+func TestMessageEventFromSlackevents(t *testing.T) {
+	t.Run("plain message", func(t *testing.T) {
+		ev := &slackevents.MessageEvent{
+			Type:            "message",
+			Channel:         "C1",
+			User:            "U1",
+			Text:            "hi",
+			TimeStamp:       "100.001",
+			ThreadTimeStamp: "100.000",
+		}
+
+		got := messageEventFromSlackevents(ev)
+
+		if got.Edited {
+			t.Error("expected a plain message not to be marked as edited")
+		}
+		if got.Channel != "C1" || got.User != "U1" || got.Text != "hi" {
+			t.Errorf("got %+v, want channel/user/text from the top-level event", got)
+		}
+		if got.ThreadTimestamp != "100.000" {
+			t.Errorf("ThreadTimestamp = %q, want %q", got.ThreadTimestamp, "100.000")
+		}
+	})
+
+	t.Run("message_changed", func(t *testing.T) {
+		ev := &slackevents.MessageEvent{
+			Type:    "message",
+			SubType: "message_changed",
+			Channel: "C1",
+			Message: &slackevents.MessageEvent{
+				User:            "U2",
+				Text:            "edited text",
+				TimeStamp:       "200.001",
+				ThreadTimeStamp: "199.000",
+			},
+		}
+
+		got := messageEventFromSlackevents(ev)
+
+		if !got.Edited {
+			t.Error("expected a message_changed event to be marked as edited")
+		}
+		if got.Channel != "C1" {
+			t.Errorf("Channel = %q, want %q from the wrapper event", got.Channel, "C1")
+		}
+		if got.User != "U2" || got.Text != "edited text" {
+			t.Errorf("got %+v, want user/text from the nested message", got)
+		}
+		if got.ThreadTimestamp != "199.000" {
+			t.Errorf("ThreadTimestamp = %q, want %q from the nested message", got.ThreadTimestamp, "199.000")
+		}
+	})
+}